@@ -5,28 +5,94 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	apiv1 "cloud.google.com/go/firestore/apiv1/admin"
+	"cloud.google.com/go/storage"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	"github.com/cloudevents/sdk-go/v2/event"
+	"google.golang.org/api/iterator"
 	"google.golang.org/genproto/googleapis/firestore/admin/v1"
+	"google.golang.org/genproto/googleapis/longrunning"
 )
 
 func init() {
 	functions.HTTP("Function", function)
+	functions.CloudEvent("FunctionPubSub", functionPubSub)
 }
 
+// defaultDatabaseId is the database id used when the request does not specify one, matching
+// Firestore's own default database name.
+const defaultDatabaseId = "(default)"
+
+// backupPrefix is the GCS namespace under which every backup() export is written, keyed by
+// database id and then by the RFC3339Nano timestamp of the snapshot.
+const backupPrefix = "firestore-backup"
+
+// defaultParallelConcurrency is the number of per-collection export operations run concurrently
+// by backupParallel when the request does not specify one.
+const defaultParallelConcurrency = 4
+
+// parallelMarkerObject marks a snapshot as having been written by backupParallel - one export per
+// collection rather than a single export at the snapshot root - which the standard restore path
+// cannot import from.
+const parallelMarkerObject = "_parallel"
+
+// operationMarkerObject records the name of the ExportDocuments operation that is writing a
+// snapshot, so that restore can tell an in-flight snapshot (the operation hasn't finished yet)
+// from a finished one when auto-selecting, without having to block on the export itself.
+const operationMarkerObject = "_operation"
+
 type backupRequest struct {
-	Action      string   `json:"action"`
-	Collections []string `json:"collections"`
-	ProjectId   string   `json:"project_id"`
-	Bucket      string   `json:"bucket"`
+	Action        string   `json:"action"`
+	Collections   []string `json:"collections"`
+	ProjectId     string   `json:"project_id"`
+	Bucket        string   `json:"bucket"`
+	DatabaseId    string   `json:"database_id"`
+	OperationName string   `json:"operation_name"`
+	BackupId      string   `json:"backup_id"`
+	RetentionDays int      `json:"retention_days"`
+	Parallel      bool     `json:"parallel"`
+	Concurrency   int      `json:"concurrency"`
+}
+
+// collectionResult reports the outcome of starting the export for a single collection under
+// backupParallel. Name is set once the operation has been started; Error is set instead if it
+// could not be started. Like backup, it does not wait for the export to finish - poll Name with
+// the "status" action to find out when it completes.
+type collectionResult struct {
+	Collection string `json:"collection"`
+	Name       string `json:"name,omitempty"`
+	OutputUri  string `json:"output_uri,omitempty"`
+	Error      string `json:"error,omitempty"`
 }
 
-// function is the entry point for the cloudfunction that will either backup a firestore database
-// or restores a firestore database from a backup. The function is triggered by a cloud scheduler
-// job. You can invoke the function manually to restore collections by calling the function with the following
-// body: {"action": "restore", "collections": ["collection1", "collection2"], "project": "my-project"}
+// operationResponse is returned for "backup"/"restore" requests once the long-running operation
+// has been started, and for "status" requests reporting on its progress.
+type operationResponse struct {
+	Name     string `json:"name"`
+	Done     bool   `json:"done"`
+	Error    string `json:"error,omitempty"`
+	BackupId string `json:"backup_id,omitempty"`
+}
+
+// function is the entry point for the cloudfunction that will either backup a firestore database,
+// restore a firestore database from a backup, or report on the status of a previously started
+// backup/restore operation. The function is triggered by a cloud scheduler job. You can invoke the
+// function manually to restore collections by calling the function with the following body:
+// {"action": "restore", "collections": ["collection1", "collection2"], "project_id": "my-project", "bucket": "my-bucket"}
+//
+// backup and restore start the underlying Firestore export/import operation and return
+// immediately with HTTP 202 and the operation name, since these operations routinely run longer
+// than a Cloud Function execution window. Poll the operation with the "status" action to find out
+// when it has finished. A parallel backup returns 202 only if every collection's export started
+// successfully; if any collection failed to start, it returns 207 Multi-Status alongside the same
+// per-collection body, so a caller doesn't mistake a partial failure for full success.
 func function(w http.ResponseWriter, r *http.Request) {
 	var req backupRequest
 
@@ -56,87 +122,588 @@ func function(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Action == "status" {
+		op, err := req.status(r.Context(), client)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		resp := operationResponse{Name: op.GetName(), Done: op.GetDone()}
+		if opErr := op.GetError(); opErr != nil {
+			resp.Error = opErr.GetMessage()
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	gcs, err := storage.NewClient(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
 	switch req.Action {
-	case "back":
-		if err := req.backup(r.Context(), client); err != nil {
+	case "backup", "restore":
+		result, err := runBackupOrRestore(r.Context(), &req, client, gcs)
+		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte(err.Error()))
 			return
 		}
-	case "restore":
-		if err := req.restore(r.Context(), client); err != nil {
+		if req.Action == "backup" && req.Parallel {
+			status := http.StatusAccepted
+			for _, c := range result.Collections {
+				if c.Error != "" {
+					status = http.StatusMultiStatus
+					break
+				}
+			}
+			writeJSON(w, status, result.Collections)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, operationResponse{Name: result.OperationName, BackupId: req.BackupId})
+	case "list_backups":
+		backups, err := req.listBackups(r.Context(), gcs)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		writeJSON(w, http.StatusOK, backups)
+	case "prune_backups":
+		pruned, err := req.pruneBackups(r.Context(), gcs)
+		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte(err.Error()))
 			return
 		}
+		writeJSON(w, http.StatusOK, pruned)
+	}
+}
+
+// pubSubMessage is the Pub/Sub envelope delivered inside a google.cloud.pubsub.topic.v1.messagePublished
+// CloudEvent. Data holds the raw JSON backupRequest published to the topic.
+type pubSubMessage struct {
+	Data []byte `json:"data"`
+}
+
+// messagePublishedData is the CloudEvent payload for a google.cloud.pubsub.topic.v1.messagePublished event.
+type messagePublishedData struct {
+	Message pubSubMessage `json:"message"`
+}
+
+// functionPubSub is the Pub/Sub-triggered entry point for the module, registered as "FunctionPubSub".
+// It accepts the same JSON backupRequest as function, published as the data of a Pub/Sub message,
+// so that Cloud Scheduler can publish to a topic instead of invoking the HTTPS function directly -
+// the recommended pattern for retries and dead-lettering, and one that allows fanning a single
+// schedule out to multiple projects by publishing multiple messages. Only backup (including
+// "parallel": true) and restore are supported here; the event is only acknowledged (by returning a
+// nil error) once every underlying operation's name has been captured, so a retried delivery
+// cannot launch a duplicate export.
+//
+// A message that fails to decode, fails validation, or names an action other than backup/restore
+// (status, list_backups and prune_backups are valid per validate but have no meaning as a
+// fire-and-forget event) is a permanent failure - redelivery would never succeed - so it is logged
+// and acknowledged rather than returned as an error, which would otherwise retry indefinitely.
+func functionPubSub(ctx context.Context, e event.Event) error {
+	var msg messagePublishedData
+	if err := e.DataAs(&msg); err != nil {
+		log.Printf("error decoding pubsub message, dropping: %v", err)
+		return nil
+	}
+
+	var req backupRequest
+	if err := json.Unmarshal(msg.Message.Data, &req); err != nil {
+		log.Printf("error decoding backup request, dropping: %v", err)
+		return nil
+	}
+
+	if err := req.validate(); err != nil {
+		log.Printf("invalid backup request, dropping: %v", err)
+		return nil
+	}
+
+	if req.Action != "backup" && req.Action != "restore" {
+		log.Printf("action %q is not supported by the pubsub entry point, dropping", req.Action)
+		return nil
+	}
+
+	client, err := apiv1.NewFirestoreAdminClient(ctx)
+	if err != nil {
+		return fmt.Errorf("error creating firestore admin client: %v", err)
+	}
+
+	gcs, err := storage.NewClient(ctx)
+	if err != nil {
+		return fmt.Errorf("error creating storage client: %v", err)
+	}
+
+	result, err := runBackupOrRestore(ctx, &req, client, gcs)
+	if err != nil {
+		return err
+	}
+
+	if req.Action == "backup" && req.Parallel {
+		var failed int
+		for _, c := range result.Collections {
+			if c.Error != "" {
+				failed++
+				log.Printf("ERROR: failed to start export for collection %s (backup %s): %s", c.Collection, req.BackupId, c.Error)
+				continue
+			}
+			log.Printf("started operation %s for collection %s (backup %s)", c.Name, c.Collection, req.BackupId)
+		}
+		if failed > 0 {
+			log.Printf("ERROR: parallel backup %s failed to start %d/%d collections", req.BackupId, failed, len(result.Collections))
+		}
+		return nil
 	}
+
+	log.Printf("started operation %s for action %s", result.OperationName, req.Action)
+
+	return nil
 }
 
-// backup will backup all collections or a subset of collections in a firestore database to a bucket.
-// This is called when the action is set to "backup" in the request body.
+// runResult is the outcome of runBackupOrRestore: OperationName for a plain backup/restore, or
+// Collections for a parallel backup.
+type runResult struct {
+	OperationName string
+	Collections   []collectionResult
+}
+
+// runBackupOrRestore runs a "backup" (including parallel) or "restore" action. It is shared by the
+// HTTP and Pub/Sub CloudEvent entry points so both honor the same request fields, such as Parallel,
+// identically.
+func runBackupOrRestore(ctx context.Context, req *backupRequest, client *apiv1.FirestoreAdminClient, gcs *storage.Client) (runResult, error) {
+	switch req.Action {
+	case "backup":
+		if req.Parallel {
+			return runResult{Collections: req.backupParallel(ctx, client, gcs)}, nil
+		}
+		name, err := req.backup(ctx, client, gcs)
+		return runResult{OperationName: name}, err
+	case "restore":
+		name, err := req.restore(ctx, client, gcs)
+		return runResult{OperationName: name}, err
+	default:
+		return runResult{}, fmt.Errorf("action %q is not supported by this entry point", req.Action)
+	}
+}
+
+// writeJSON marshals v and writes it to w with the given status code.
+func writeJSON(w http.ResponseWriter, statusCode int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
+
+// snapshotPrefix returns the GCS namespace prefix (without the gs://BUCKET part) under which
+// backup() writes timestamped snapshots for this request's database.
+func (r *backupRequest) snapshotPrefix() string {
+	return fmt.Sprintf("%s/%s/", backupPrefix, r.DatabaseId)
+}
+
+// backup will backup all collections or a subset of collections in a firestore database to a
+// timestamped subdirectory of the bucket so that multiple point-in-time snapshots can coexist.
+// This is called when the action is set to "backup" in the request body. It returns the name of
+// the long-running operation as soon as the export has been started; it does not wait for the
+// export to finish. Poll the operation with the "status" action to find out when it completes.
+// The snapshot directory appears in GCS as soon as the export starts writing, so backup also
+// records the operation's name in an operation marker alongside it - restore's auto-selection
+// uses this to tell an in-flight snapshot from a finished one before treating it as restorable.
 // Name: The database resource name. For example: projects/{project_id}/databases/{database_id}
 // CollectionIds: The collection IDs to export. Unspecified means all collections.
 // OutputUriPrefix: Supports Google Cloud Storage URIs of the form: gs://BUCKET_NAME[/NAMESPACE_PATH]
 // By default all collections will be exported to the bucket with a namespace of firestore-backup
-func (r *backupRequest) backup(ctx context.Context, client *apiv1.FirestoreAdminClient) error {
+func (r *backupRequest) backup(ctx context.Context, client *apiv1.FirestoreAdminClient, gcs *storage.Client) (string, error) {
+	// Nanosecond resolution so two overlapping backup runs (e.g. a manual invoke racing the
+	// scheduled one) don't collide on the same GCS prefix and interleave their exports.
+	r.BackupId = time.Now().UTC().Format(time.RFC3339Nano)
+
 	op, err := client.ExportDocuments(ctx, &admin.ExportDocumentsRequest{
-		Name:            fmt.Sprintf("projects/%s/databases/(default)", r.ProjectId),
+		Name:            fmt.Sprintf("projects/%s/databases/%s", r.ProjectId, r.DatabaseId),
 		CollectionIds:   r.Collections,
-		OutputUriPrefix: fmt.Sprintf("gs://%s/firestore-backup", r.Bucket),
+		OutputUriPrefix: fmt.Sprintf("gs://%s/%s%s", r.Bucket, r.snapshotPrefix(), r.BackupId),
 	}, nil)
 	if err != nil {
-		return fmt.Errorf("error backing up firestore database: %v", err)
+		return "", fmt.Errorf("error backing up firestore database: %v", err)
 	}
 
-	if _, err := op.Wait(ctx); err != nil {
-		return fmt.Errorf("error backing up firestore database: %v", err)
+	if err := r.writeSnapshotMarker(ctx, gcs, r.BackupId, operationMarkerObject, []byte(op.Name())); err != nil {
+		return "", fmt.Errorf("error recording operation marker for backup %s: %v", r.BackupId, err)
 	}
 
-	return nil
+	return op.Name(), nil
+}
+
+// backupParallel starts one ExportDocuments operation per collection in r.Collections, launched
+// through a bounded worker pool (Concurrency, default defaultParallelConcurrency), and returns as
+// soon as every operation has been started - it does not wait for them to finish, for the same
+// reason backup doesn't: these exports routinely outlive a Cloud Function execution window. This
+// is called when the action is set to "backup" with "parallel": true in the request body. Unlike
+// backup, it isolates failures to the collection that caused them and reports a structured
+// per-collection result instead of a single opaque error.
+//
+// Because each collection is exported to its own subdirectory, a parallel snapshot has no single
+// root-level export for restore to import from; it is marked as such so restore can refuse to
+// auto-select or import it via the standard path.
+func (r *backupRequest) backupParallel(ctx context.Context, client *apiv1.FirestoreAdminClient, gcs *storage.Client) []collectionResult {
+	concurrency := r.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultParallelConcurrency
+	}
+
+	r.BackupId = time.Now().UTC().Format(time.RFC3339Nano)
+
+	if err := r.writeSnapshotMarker(ctx, gcs, r.BackupId, parallelMarkerObject, []byte(strings.Join(r.Collections, ","))); err != nil {
+		return []collectionResult{{Error: fmt.Sprintf("error preparing parallel backup: %v", err)}}
+	}
+
+	results := make([]collectionResult, len(r.Collections))
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for i, collection := range r.Collections {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, collection string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = r.exportCollection(ctx, client, collection)
+		}(i, collection)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// exportCollection starts the export operation for a single collection, returning its outcome as
+// a collectionResult rather than an error so the caller can isolate per-collection failures. It
+// does not wait for the export to finish.
+func (r *backupRequest) exportCollection(ctx context.Context, client *apiv1.FirestoreAdminClient, collection string) collectionResult {
+	outputUri := fmt.Sprintf("gs://%s/%s%s/%s", r.Bucket, r.snapshotPrefix(), r.BackupId, collection)
+	result := collectionResult{Collection: collection, OutputUri: outputUri}
+
+	op, err := client.ExportDocuments(ctx, &admin.ExportDocumentsRequest{
+		Name:            fmt.Sprintf("projects/%s/databases/%s", r.ProjectId, r.DatabaseId),
+		CollectionIds:   []string{collection},
+		OutputUriPrefix: outputUri,
+	}, nil)
+	if err != nil {
+		result.Error = fmt.Sprintf("error backing up collection %s: %v", collection, err)
+		return result
+	}
+	result.Name = op.Name()
+
+	return result
 }
 
-// restore will restore all collections or a subset of collections in a firestore database from a bucket.
-// This is called when the action is set to "restore" in the request body.
+// restore will restore all collections or a subset of collections in a firestore database from a
+// snapshot previously written by backup. This is called when the action is set to "restore" in
+// the request body. If BackupId is unset, the most recent snapshot under the bucket that is both
+// non-parallel and whose export operation has finished is used; parallel snapshots have no
+// root-level export for ImportDocuments to read, and in-flight ones would import partial data, so
+// both are skipped when auto-selecting. If BackupId is set explicitly, restore only rejects it for
+// being a parallel snapshot - an explicit, still-running backup_id is allowed through, since the
+// caller is assumed to know what they're doing. It returns the name of the long-running operation
+// as soon as the import has been started; it does not wait for the import to finish. Poll the
+// operation with the "status" action to find out when it completes.
 // Name: The database resource name. For example: projects/{project_id}/databases/{database_id}
 // CollectionIds: The collection IDs to export. Unspecified means all collections.
 // InputUriPrefix: Supports Google Cloud Storage URIs of the form: gs://BUCKET_NAME[/NAMESPACE_PATH]
-func (r *backupRequest) restore(ctx context.Context, client *apiv1.FirestoreAdminClient) error {
+func (r *backupRequest) restore(ctx context.Context, client *apiv1.FirestoreAdminClient, gcs *storage.Client) (string, error) {
+	if r.BackupId == "" {
+		snapshot, err := r.latestRestorableSnapshot(ctx, client, gcs)
+		if err != nil {
+			return "", err
+		}
+		r.BackupId = snapshot
+	} else if parallel, err := r.isParallelSnapshot(ctx, gcs, r.BackupId); err != nil {
+		return "", err
+	} else if parallel {
+		return "", fmt.Errorf("backup %s was written by a parallel backup and has no root-level export; restore each collection individually instead", r.BackupId)
+	}
+
 	op, err := client.ImportDocuments(ctx, &admin.ImportDocumentsRequest{
-		Name:           fmt.Sprintf("projects/%s/databases/(default)", r.ProjectId),
+		Name:           fmt.Sprintf("projects/%s/databases/%s", r.ProjectId, r.DatabaseId),
 		CollectionIds:  r.Collections,
-		InputUriPrefix: fmt.Sprintf("gs://%s/firestore-backup", r.Bucket),
+		InputUriPrefix: fmt.Sprintf("gs://%s/%s%s", r.Bucket, r.snapshotPrefix(), r.BackupId),
 	}, nil)
 	if err != nil {
-		return fmt.Errorf("error restoring firestore database: %v", err)
+		return "", fmt.Errorf("error restoring firestore database: %v", err)
+	}
+
+	return op.Name(), nil
+}
+
+// latestRestorableSnapshot returns the most recent snapshot under the bucket that is both a
+// completed export and not a parallel backup, so restore doesn't auto-select an in-flight or
+// unimportable snapshot by accident.
+func (r *backupRequest) latestRestorableSnapshot(ctx context.Context, client *apiv1.FirestoreAdminClient, gcs *storage.Client) (string, error) {
+	snapshots, err := r.listSnapshots(ctx, gcs)
+	if err != nil {
+		return "", err
+	}
+
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		snapshot := snapshots[i]
+
+		parallel, err := r.isParallelSnapshot(ctx, gcs, snapshot)
+		if err != nil {
+			return "", err
+		}
+		if parallel {
+			continue
+		}
+
+		complete, err := r.isSnapshotComplete(ctx, client, gcs, snapshot)
+		if err != nil {
+			return "", err
+		}
+		if !complete {
+			continue
+		}
+
+		return snapshot, nil
+	}
+
+	return "", fmt.Errorf("no completed, restorable backups found under gs://%s/%s", r.Bucket, r.snapshotPrefix())
+}
+
+// isParallelSnapshot reports whether a snapshot was written by backupParallel, and so has no
+// root-level export the standard restore path can import.
+func (r *backupRequest) isParallelSnapshot(ctx context.Context, gcs *storage.Client, snapshot string) (bool, error) {
+	return r.snapshotMarkerExists(ctx, gcs, snapshot, parallelMarkerObject)
+}
+
+// isSnapshotComplete reports whether the export operation that wrote a snapshot has finished, by
+// polling the operation name recorded in its operation marker. A snapshot with no operation marker
+// (written before this check existed, or still being written) is treated as incomplete.
+func (r *backupRequest) isSnapshotComplete(ctx context.Context, client *apiv1.FirestoreAdminClient, gcs *storage.Client, snapshot string) (bool, error) {
+	name, err := r.readSnapshotMarker(ctx, gcs, snapshot, operationMarkerObject)
+	if err != nil {
+		return false, err
+	}
+	if name == "" {
+		return false, nil
+	}
+
+	op, err := client.LROClient.GetOperation(ctx, &longrunning.GetOperationRequest{Name: name})
+	if err != nil {
+		return false, fmt.Errorf("error checking backup %s status: %v", snapshot, err)
+	}
+
+	return op.GetDone(), nil
+}
+
+// writeSnapshotMarker writes a small marker object under a snapshot's prefix, used to record
+// metadata about the snapshot (such as its operation name or that it was written in parallel)
+// alongside the exported data.
+func (r *backupRequest) writeSnapshotMarker(ctx context.Context, gcs *storage.Client, snapshot, object string, data []byte) error {
+	w := gcs.Bucket(r.Bucket).Object(fmt.Sprintf("%s%s/%s", r.snapshotPrefix(), snapshot, object)).NewWriter(ctx)
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return fmt.Errorf("error writing %s marker for backup %s: %v", object, snapshot, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("error writing %s marker for backup %s: %v", object, snapshot, err)
+	}
+	return nil
+}
+
+// readSnapshotMarker reads a marker object written by writeSnapshotMarker, returning "" if it does
+// not exist.
+func (r *backupRequest) readSnapshotMarker(ctx context.Context, gcs *storage.Client, snapshot, object string) (string, error) {
+	reader, err := gcs.Bucket(r.Bucket).Object(fmt.Sprintf("%s%s/%s", r.snapshotPrefix(), snapshot, object)).NewReader(ctx)
+	if err == storage.ErrObjectNotExist {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error reading %s marker for backup %s: %v", object, snapshot, err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("error reading %s marker for backup %s: %v", object, snapshot, err)
+	}
+
+	return string(data), nil
+}
+
+// snapshotMarkerExists reports whether a marker object written by writeSnapshotMarker exists.
+func (r *backupRequest) snapshotMarkerExists(ctx context.Context, gcs *storage.Client, snapshot, object string) (bool, error) {
+	_, err := gcs.Bucket(r.Bucket).Object(fmt.Sprintf("%s%s/%s", r.snapshotPrefix(), snapshot, object)).Attrs(ctx)
+	if err == storage.ErrObjectNotExist {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error checking %s marker for backup %s: %v", object, snapshot, err)
+	}
+	return true, nil
+}
+
+// listSnapshots lists the RFC3339Nano timestamp snapshot directories that exist under this
+// request's database prefix, sorted chronologically with the oldest first.
+func (r *backupRequest) listSnapshots(ctx context.Context, gcs *storage.Client) ([]string, error) {
+	it := gcs.Bucket(r.Bucket).Objects(ctx, &storage.Query{
+		Prefix:    r.snapshotPrefix(),
+		Delimiter: "/",
+	})
+
+	var snapshots []string
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error listing backups: %v", err)
+		}
+		if attrs.Prefix == "" {
+			continue
+		}
+		snapshots = append(snapshots, strings.TrimSuffix(strings.TrimPrefix(attrs.Prefix, r.snapshotPrefix()), "/"))
+	}
+
+	// RFC3339Nano trims trailing zeros from the fraction, so ids are compared as parsed times
+	// rather than as strings; names that do not parse sort first, by name.
+	sort.Slice(snapshots, func(i, j int) bool {
+		ti, errI := time.Parse(time.RFC3339Nano, snapshots[i])
+		tj, errJ := time.Parse(time.RFC3339Nano, snapshots[j])
+		if errI != nil || errJ != nil {
+			if (errI != nil) != (errJ != nil) {
+				return errI != nil
+			}
+			return snapshots[i] < snapshots[j]
+		}
+		return ti.Before(tj)
+	})
+
+	return snapshots, nil
+}
+
+// listBackups returns the snapshot timestamps available under gs://BUCKET/firestore-backup/ for
+// this request's database. This is called when the action is set to "list_backups". Unlike
+// restore's auto-selection, it does not filter out in-flight or parallel snapshots - the most
+// recent entry may not yet be restorable; check it with "status" before passing it as backup_id.
+func (r *backupRequest) listBackups(ctx context.Context, gcs *storage.Client) ([]string, error) {
+	return r.listSnapshots(ctx, gcs)
+}
+
+// pruneBackups deletes snapshots older than RetentionDays, keeping the rest. This is called when
+// the action is set to "prune_backups". It returns the ids of the snapshots that were deleted.
+func (r *backupRequest) pruneBackups(ctx context.Context, gcs *storage.Client) ([]string, error) {
+	snapshots, err := r.listSnapshots(ctx, gcs)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := op.Wait(ctx); err != nil {
-		return fmt.Errorf("error backing up firestore database: %v", err)
+	cutoff := time.Now().UTC().AddDate(0, 0, -r.RetentionDays)
+
+	var pruned []string
+	for _, snapshot := range snapshots {
+		ts, err := time.Parse(time.RFC3339Nano, snapshot)
+		if err != nil {
+			continue
+		}
+		if ts.After(cutoff) {
+			continue
+		}
+
+		if err := r.deleteSnapshot(ctx, gcs, snapshot); err != nil {
+			return pruned, err
+		}
+		pruned = append(pruned, snapshot)
+	}
+
+	return pruned, nil
+}
+
+// deleteSnapshot deletes every object under a single snapshot's prefix.
+func (r *backupRequest) deleteSnapshot(ctx context.Context, gcs *storage.Client, snapshot string) error {
+	bucket := gcs.Bucket(r.Bucket)
+	it := bucket.Objects(ctx, &storage.Query{
+		Prefix: fmt.Sprintf("%s%s/", r.snapshotPrefix(), snapshot),
+	})
+
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error listing objects for backup %s: %v", snapshot, err)
+		}
+		if err := bucket.Object(attrs.Name).Delete(ctx); err != nil {
+			return fmt.Errorf("error deleting object %s: %v", attrs.Name, err)
+		}
 	}
 
 	return nil
 }
 
+// status reports on the progress of a long-running export/import operation previously started by
+// backup or restore. This is called when the action is set to "status" in the request body, with
+// OperationName set to the name returned by that earlier call.
+func (r *backupRequest) status(ctx context.Context, client *apiv1.FirestoreAdminClient) (*longrunning.Operation, error) {
+	op, err := client.LROClient.GetOperation(ctx, &longrunning.GetOperationRequest{
+		Name: r.OperationName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting operation status: %v", err)
+	}
+
+	return op, nil
+}
+
 // validate will validate the request body to ensure that the required fields are present.
 func (r *backupRequest) validate() error {
-	if r.ProjectId == "" {
-		return fmt.Errorf("project is required")
+	if r.Action == "" {
+		return fmt.Errorf("action is required")
+	}
+
+	switch r.Action {
+	case "backup", "restore", "status", "list_backups", "prune_backups":
+	default:
+		return fmt.Errorf("action must be one of backup, restore, status, list_backups or prune_backups")
+	}
+
+	if r.Action == "status" {
+		if r.OperationName == "" {
+			return fmt.Errorf("operation_name is required when action is status")
+		}
+		return nil
 	}
 
 	if r.Bucket == "" {
 		return fmt.Errorf("bucket is required")
 	}
 
-	if r.Action == "" {
-		return fmt.Errorf("action is required")
+	if r.DatabaseId == "" {
+		r.DatabaseId = defaultDatabaseId
+	}
+
+	if r.Action == "list_backups" || r.Action == "prune_backups" {
+		if r.Action == "prune_backups" && r.RetentionDays <= 0 {
+			return fmt.Errorf("retention_days must be greater than zero when action is prune_backups")
+		}
+		return nil
 	}
 
-	if r.Action != "backup" && r.Action != "restore" {
-		return fmt.Errorf("action must be either backup or restore")
+	if r.ProjectId == "" {
+		return fmt.Errorf("project is required")
 	}
 
-	if r.Action == "backup" && r.Bucket == "" {
-		return fmt.Errorf("bucket is required when action is backup")
+	if r.Action == "backup" && r.Parallel && len(r.Collections) == 0 {
+		return fmt.Errorf("collections is required when action is backup and parallel is true")
 	}
 
 	return nil